@@ -0,0 +1,491 @@
+// Package config handles reading and writing of the various registry
+// credential files (Docker's config.json/.dockercfg and Podman's auth.json)
+// that are understood by GetCredentials and friends.
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/homedir"
+	"github.com/pkg/errors"
+)
+
+// dockerConfigFile is the on-disk representation of a Docker/Podman
+// credential file ("auths" keyed by registry scope).
+type dockerConfigFile struct {
+	AuthConfigs map[string]dockerAuthConfig `json:"auths"`
+	CredHelpers map[string]string           `json:"credHelpers,omitempty"`
+	CredsStore  string                      `json:"credsStore,omitempty"`
+	HTTPHeaders map[string]string           `json:"HttpHeaders,omitempty"`
+	Proxies     map[string]proxyConfig      `json:"proxies,omitempty"`
+}
+
+// dockerAuthConfig is a single entry of dockerConfigFile.AuthConfigs.
+type dockerAuthConfig struct {
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// authPath is a candidate credential file to search, and whether it is
+// stored in the legacy (.dockercfg, unwrapped) format.
+type authPath struct {
+	path         string
+	legacyFormat bool
+}
+
+var (
+	defaultPerUIDPathFormat = filepath.FromSlash("/run/containers/%d/auth.json")
+	xdgRuntimeDirPath       = filepath.FromSlash("containers/auth.json")
+	dockerHomePath          = filepath.FromSlash(".docker/config.json")
+	dockerLegacyHomePath    = ".dockercfg"
+	nonLinuxAuthFilePath    = filepath.FromSlash(".config/containers/auth.json")
+
+	// ErrNotLoggedIn is returned by RemoveAuthentication when no credentials
+	// are stored for the given registry.
+	ErrNotLoggedIn = errors.New("not logged in")
+)
+
+// SetAuthentication stores the username and password for registry in the
+// authentication file governed by sys.
+func SetAuthentication(sys *types.SystemContext, registry, username, password string) error {
+	return SetCredentials(sys, registry, username, password)
+}
+
+// SetCredentials stores the username and password for scope (a registry
+// hostname, or a repository reference scope such as "quay.io/libpod") in
+// the authentication file governed by sys. If a credsStore or a
+// credHelpers entry applies to scope, the credentials are stored there
+// instead, and the JSON file is left untouched. External credential
+// helpers only understand host-scoped ServerURLs, so the credentials are
+// always stored under scope's bare host, never a repository path.
+func SetCredentials(sys *types.SystemContext, scope, username, password string) error {
+	return modifyJSON(sys, func(auths *dockerConfigFile) (bool, error) {
+		if helper := credHelperFor(auths, scope); helper != "" {
+			host, _ := normalizeAuthFileKey(scope)
+			if err := setCredHelperCredentials(helper, host, username, password); err != nil {
+				return false, err
+			}
+			return false, nil
+		}
+
+		creds := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		newCreds := dockerAuthConfig{Auth: creds}
+		auths.AuthConfigs[scope] = newCreds
+		return true, nil
+	})
+}
+
+// GetAllCredentials returns the registry credentials, keyed by the
+// normalized form (see normalizeAuthFileKey) of the scope (registry, or
+// repository-scoped entry) they were stored under, as found in the
+// authentication file governed by sys.
+func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthConfig, error) {
+	path, legacyFormat, err := getPathToAuth(sys)
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := readJSONFile(path, legacyFormat)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading JSON file %q", path)
+	}
+
+	result := make(map[string]types.DockerAuthConfig)
+
+	if !legacyFormat {
+		for registry, helper := range auths.CredHelpers {
+			creds, err := getCredHelperCredentials(helper, registry)
+			if err != nil {
+				return nil, err
+			}
+			if creds != (types.DockerAuthConfig{}) {
+				result[registry] = creds
+			}
+		}
+
+		if auths.CredsStore != "" {
+			registries, err := listCredHelperRegistries(auths.CredsStore)
+			if err != nil {
+				return nil, err
+			}
+			for _, registry := range registries {
+				if _, handledByRegistryHelper := auths.CredHelpers[registry]; handledByRegistryHelper {
+					continue
+				}
+				creds, err := getCredHelperCredentials(auths.CredsStore, registry)
+				if err != nil {
+					return nil, err
+				}
+				if creds != (types.DockerAuthConfig{}) {
+					result[registry] = creds
+				}
+			}
+		}
+	}
+
+	for key, entry := range auths.AuthConfigs {
+		conf, err := decodeDockerAuth(entry)
+		if err != nil {
+			return nil, err
+		}
+		_, normalized := normalizeAuthFileKey(key)
+		if _, handledByHelper := result[normalized]; !handledByHelper {
+			result[normalized] = conf
+		}
+	}
+
+	return result, nil
+}
+
+// GetCredentials returns the registry credentials stored in the
+// authentication file for key, which may be either a registry hostname
+// (e.g. "quay.io") or a repository reference scope (e.g.
+// "quay.io/libpod/podman:latest"). If no credentials are found, an empty
+// types.DockerAuthConfig is returned.
+func GetCredentials(sys *types.SystemContext, key string) (types.DockerAuthConfig, error) {
+	return getCredentialsWithHomeDir(sys, key, homedir.Get())
+}
+
+func getCredentialsWithHomeDir(sys *types.SystemContext, key, homeDir string) (types.DockerAuthConfig, error) {
+	if sys != nil && sys.DockerAuthConfig != nil {
+		return *sys.DockerAuthConfig, nil
+	}
+
+	paths, err := getCredentialSearchPaths(sys, homeDir)
+	if err != nil {
+		return types.DockerAuthConfig{}, err
+	}
+
+	host, target := normalizeAuthFileKey(key)
+
+	for _, p := range paths {
+		auth, found, err := lookupCredentialsInPath(p, host, target)
+		if err != nil {
+			return types.DockerAuthConfig{}, err
+		}
+		if found {
+			return auth, nil
+		}
+	}
+
+	return types.DockerAuthConfig{}, nil
+}
+
+// lookupCredentialsInPath looks up the credentials for (host, target) in
+// the single credential file described by p. found is true if p applies
+// (i.e. a credential helper handled host, or an auths entry matched),
+// even if the resulting types.DockerAuthConfig turns out to be empty.
+func lookupCredentialsInPath(p authPath, host, target string) (auth types.DockerAuthConfig, found bool, err error) {
+	if _, err := os.Stat(p.path); err != nil {
+		if os.IsNotExist(err) {
+			return types.DockerAuthConfig{}, false, nil
+		}
+		return types.DockerAuthConfig{}, false, err
+	}
+
+	auths, err := readJSONFile(p.path, p.legacyFormat)
+	if err != nil {
+		return types.DockerAuthConfig{}, false, errors.Wrapf(err, "error reading JSON file %q", p.path)
+	}
+
+	if !p.legacyFormat {
+		if helper := credHelperFor(&auths, host); helper != "" {
+			// External credential helpers only ever store entries under
+			// the bare host (that's all SetCredentials ever writes to
+			// them), so query by host, not by the possibly repository-
+			// scoped target.
+			auth, err := getCredHelperCredentials(helper, host)
+			return auth, true, err
+		}
+	}
+
+	if entry, ok := findScopeEntry(auths.AuthConfigs, target); ok {
+		auth, err := decodeDockerAuth(entry)
+		return auth, true, err
+	}
+
+	return types.DockerAuthConfig{}, false, nil
+}
+
+// findScopeEntry returns the most specific entry in auths that applies to
+// target, using longest-prefix matching over the normalized host+path of
+// both target and the map's keys. An entry with no path component
+// ("example.org") matches any path under that host, but never takes
+// precedence over an entry that specifies a path itself
+// ("example.org/vendor").
+func findScopeEntry(auths map[string]dockerAuthConfig, target string) (dockerAuthConfig, bool) {
+	var (
+		bestMatch dockerAuthConfig
+		bestLen   = -1
+		found     bool
+	)
+
+	for key, entry := range auths {
+		_, normalized := normalizeAuthFileKey(key)
+		if normalized != target && !strings.HasPrefix(target, normalized+"/") {
+			continue
+		}
+		if len(normalized) > bestLen {
+			bestMatch = entry
+			bestLen = len(normalized)
+			found = true
+		}
+	}
+
+	return bestMatch, found
+}
+
+// GetAuthentication returns the registry credentials stored in the
+// authentication file for registry, as set by SetAuthentication.
+//
+// Deprecated: Use GetCredentials instead.
+func GetAuthentication(sys *types.SystemContext, registry string) (string, string, error) {
+	return getAuthenticationWithHomeDir(sys, registry, homedir.Get())
+}
+
+func getAuthenticationWithHomeDir(sys *types.SystemContext, registry, homeDir string) (string, string, error) {
+	auth, err := getCredentialsWithHomeDir(sys, registry, homeDir)
+	if err != nil {
+		return "", "", err
+	}
+	if auth.IdentityToken != "" {
+		return "", "", errors.New("non-empty identity token found and this API doesn't support it")
+	}
+	return auth.Username, auth.Password, nil
+}
+
+// RemoveAuthentication removes credentials for key (a registry hostname or
+// a repository-scoped key) from the authentication file governed by sys.
+func RemoveAuthentication(sys *types.SystemContext, key string) error {
+	return RemoveCredentials(sys, key)
+}
+
+// RemoveCredentials removes the exact scope entry from the authentication
+// file governed by sys. Unlike GetCredentials, it does not fall back to a
+// less-specific entry. If a credsStore or a credHelpers entry applies to
+// scope, the credentials stored for scope's bare host are erased from that
+// helper instead, as that is where SetCredentials stores them.
+func RemoveCredentials(sys *types.SystemContext, scope string) error {
+	return modifyJSON(sys, func(auths *dockerConfigFile) (bool, error) {
+		if helper := credHelperFor(auths, scope); helper != "" {
+			host, _ := normalizeAuthFileKey(scope)
+			return false, eraseCredHelperCredentials(helper, host)
+		}
+
+		if _, ok := auths.AuthConfigs[scope]; !ok {
+			return false, ErrNotLoggedIn
+		}
+		delete(auths.AuthConfigs, scope)
+		return true, nil
+	})
+}
+
+// RemoveAllAuthentication deletes all the credentials stored in the
+// authentication file governed by sys.
+func RemoveAllAuthentication(sys *types.SystemContext) error {
+	return modifyJSON(sys, func(auths *dockerConfigFile) (bool, error) {
+		auths.AuthConfigs = map[string]dockerAuthConfig{}
+		return true, nil
+	})
+}
+
+// decodeDockerAuth decodes the "auth" (base64(username:password)) and
+// "identitytoken" fields of entry into a types.DockerAuthConfig.
+func decodeDockerAuth(entry dockerAuthConfig) (types.DockerAuthConfig, error) {
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return types.DockerAuthConfig{}, err
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		// Docker writes "user" with no colon to mean the IdentityToken is
+		// all that is relevant.
+		return types.DockerAuthConfig{
+			Username:      parts[0],
+			Password:      "",
+			IdentityToken: entry.IdentityToken,
+		}, nil
+	}
+
+	return types.DockerAuthConfig{
+		Username:      parts[0],
+		Password:      parts[1],
+		IdentityToken: entry.IdentityToken,
+	}, nil
+}
+
+// normalizeAuthFileKey returns both the bare host (legacy, host-only
+// normalization) and the host+path form (with any tag or digest removed)
+// for key, a registry hostname or a repository reference/scope.
+//
+// "https://index.docker.io/v1" (and its variants) normalize to "docker.io",
+// matching the behavior of the Docker CLI and clients.
+func normalizeAuthFileKey(key string) (host, full string) {
+	stripped := strings.TrimPrefix(key, "https://")
+	stripped = strings.TrimPrefix(stripped, "http://")
+
+	if i := strings.LastIndex(stripped, "@"); i != -1 {
+		stripped = stripped[:i]
+	}
+
+	hostPart := stripped
+	rest := ""
+	if i := strings.IndexByte(stripped, '/'); i != -1 {
+		hostPart = stripped[:i]
+		rest = stripped[i:]
+	}
+
+	if i := strings.LastIndex(rest, ":"); i != -1 {
+		rest = rest[:i]
+	}
+
+	switch hostPart {
+	case "registry-1.docker.io", "index.docker.io":
+		hostPart = "docker.io"
+	}
+	if hostPart == "docker.io" && (rest == "/v1" || rest == "/v1/") {
+		rest = ""
+	}
+
+	return hostPart, hostPart + rest
+}
+
+// getCredentialSearchPaths returns, in priority order, the credential files
+// that GetCredentials should consult for sys and homeDir.
+func getCredentialSearchPaths(sys *types.SystemContext, homeDir string) ([]authPath, error) {
+	primaryPath, legacyFormat, err := getPathToAuth(sys)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []authPath{{path: primaryPath, legacyFormat: legacyFormat}}
+	if sys == nil || (sys.AuthFilePath == "" && sys.LegacyFormatAuthFilePath == "") {
+		paths = append(paths,
+			authPath{path: filepath.Join(homeDir, dockerHomePath), legacyFormat: false},
+			authPath{path: filepath.Join(homeDir, dockerLegacyHomePath), legacyFormat: true},
+		)
+	}
+	return paths, nil
+}
+
+// getPathToAuth returns the path to the applicable authentication file,
+// along with whether it is in the legacy (unwrapped) format.
+func getPathToAuth(sys *types.SystemContext) (string, bool, error) {
+	return getPathToAuthWithOS(sys, runtime.GOOS)
+}
+
+// getPathToAuthWithOS is an internal implementation detail of getPathToAuth,
+// exposing the target OS for testing purposes.
+func getPathToAuthWithOS(sys *types.SystemContext, goOS string) (string, bool, error) {
+	if sys != nil {
+		if sys.AuthFilePath != "" {
+			return sys.AuthFilePath, false, nil
+		}
+		if sys.LegacyFormatAuthFilePath != "" {
+			return sys.LegacyFormatAuthFilePath, true, nil
+		}
+	}
+
+	// RootForImplicitAbsolutePaths describes a root filesystem (not
+	// necessarily this host's), which always uses the Linux per-UID
+	// default layout regardless of the host OS.
+	if sys != nil && sys.RootForImplicitAbsolutePaths != "" {
+		return filepath.Join(sys.RootForImplicitAbsolutePaths, fmt.Sprintf(defaultPerUIDPathFormat, os.Getuid())), false, nil
+	}
+
+	if goOS != "linux" {
+		return filepath.Join(homedir.Get(), nonLinuxAuthFilePath), false, nil
+	}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir != "" {
+		if _, err := os.Stat(runtimeDir); err != nil {
+			return "", false, errors.Wrapf(err, "error accessing directory %q", runtimeDir)
+		}
+		return filepath.Join(runtimeDir, xdgRuntimeDirPath), false, nil
+	}
+
+	return fmt.Sprintf(defaultPerUIDPathFormat, os.Getuid()), false, nil
+}
+
+// readJSONFile reads and parses the credential file at path. A nonexistent
+// path is treated as an empty file. legacyFormat selects between Docker's
+// wrapped ("auths": {...}) format and the older, unwrapped .dockercfg
+// format.
+func readJSONFile(path string, legacyFormat bool) (dockerConfigFile, error) {
+	var auths dockerConfigFile
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		auths.AuthConfigs = map[string]dockerAuthConfig{}
+		return auths, nil
+	} else if err != nil {
+		return dockerConfigFile{}, err
+	}
+
+	if legacyFormat {
+		if err = json.Unmarshal(raw, &auths.AuthConfigs); err != nil {
+			return dockerConfigFile{}, errors.Wrapf(err, "error unmarshaling JSON at %q", path)
+		}
+		return auths, nil
+	}
+
+	if err = json.Unmarshal(raw, &auths); err != nil {
+		return dockerConfigFile{}, errors.Wrapf(err, "error unmarshaling JSON at %q", path)
+	}
+	if auths.AuthConfigs == nil {
+		auths.AuthConfigs = map[string]dockerAuthConfig{}
+	}
+	return auths, nil
+}
+
+// modifyJSON reads the authentication file governed by sys, calls editor on
+// its contents, and writes the result back if editor reports a change.
+func modifyJSON(sys *types.SystemContext, editor func(auths *dockerConfigFile) (bool, error)) error {
+	path, legacyFormat, err := getPathToAuth(sys)
+	if err != nil {
+		return err
+	}
+	if legacyFormat {
+		return errors.Errorf("writes to %s using legacy format are not supported", path)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	auths, err := readJSONFile(path, false)
+	if err != nil {
+		return errors.Wrapf(err, "error reading JSON file %q", path)
+	}
+
+	updated, err := editor(&auths)
+	if err != nil {
+		if err == ErrNotLoggedIn {
+			return err
+		}
+		return errors.Wrapf(err, "error updating %q", path)
+	}
+	if !updated {
+		return nil
+	}
+
+	newData, err := json.MarshalIndent(auths, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling JSON %q", path)
+	}
+	if err := ioutil.WriteFile(path, newData, 0600); err != nil {
+		return errors.Wrapf(err, "error writing to file %q", path)
+	}
+	return nil
+}
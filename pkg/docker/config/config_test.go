@@ -517,3 +517,95 @@ func TestGetAllCredentials(t *testing.T) {
 	}
 
 }
+
+func TestGetCredentialsNamespaced(t *testing.T) {
+	tmpHomeDir, err := ioutil.TempDir("", "test_docker_client_get_auth")
+	require.NoError(t, err)
+	t.Logf("using temporary home directory: %q", tmpHomeDir)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tmpHomeDir))
+	}()
+
+	authFilePath := filepath.Join(tmpHomeDir, "auth.json")
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "namespaced.json"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(authFilePath, contents, 0640))
+
+	sys := &types.SystemContext{AuthFilePath: authFilePath}
+
+	for _, tc := range []struct {
+		name     string
+		key      string
+		expected types.DockerAuthConfig
+	}{
+		{
+			name: "bare host",
+			key:  "docker.io",
+			expected: types.DockerAuthConfig{
+				Username: "docker",
+				Password: "io",
+			},
+		},
+		{
+			name: "namespaced entry wins over bare host",
+			key:  "docker.io/vendor/img:tag",
+			expected: types.DockerAuthConfig{
+				Username: "vendor",
+				Password: "creds",
+			},
+		},
+		{
+			name: "unrelated path falls back to bare host",
+			key:  "docker.io/other/img",
+			expected: types.DockerAuthConfig{
+				Username: "docker",
+				Password: "io",
+			},
+		},
+		{
+			name:     "sibling path does not match namespaced entry",
+			key:      "docker.io/vendored/img",
+			expected: types.DockerAuthConfig{Username: "docker", Password: "io"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			auth, err := GetCredentials(sys, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, auth)
+		})
+	}
+}
+
+func TestSetAndRemoveCredentialsNamespaced(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	_, err = tmpFile.Write([]byte{'{', '}'})
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+	defer os.RemoveAll(tmpFile.Name())
+
+	require.NoError(t, SetCredentials(sys, "quay.io", "quay-user", "quay-password"))
+	require.NoError(t, SetCredentials(sys, "quay.io/libpod", "libpod-user", "libpod-password"))
+
+	generic, err := GetCredentials(sys, "quay.io/other/img")
+	require.NoError(t, err)
+	assert.Equal(t, "quay-user", generic.Username)
+
+	scoped, err := GetCredentials(sys, "quay.io/libpod/podman:latest")
+	require.NoError(t, err)
+	assert.Equal(t, "libpod-user", scoped.Username)
+
+	all, err := GetAllCredentials(sys)
+	require.NoError(t, err)
+	assert.Contains(t, all, "quay.io")
+	assert.Contains(t, all, "quay.io/libpod")
+
+	require.NoError(t, RemoveCredentials(sys, "quay.io/libpod"))
+	scoped, err = GetCredentials(sys, "quay.io/libpod/podman:latest")
+	require.NoError(t, err)
+	assert.Equal(t, "quay-user", scoped.Username)
+
+	err = RemoveCredentials(sys, "quay.io/libpod")
+	assert.Equal(t, ErrNotLoggedIn, err)
+}
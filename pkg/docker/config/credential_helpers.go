@@ -0,0 +1,87 @@
+package config
+
+import (
+	"github.com/containers/image/v5/types"
+	helperclient "github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+)
+
+// credHelperFor returns the name of the external credential helper (as it
+// would appear in credHelpers/credsStore, without the "docker-credential-"
+// prefix) responsible for key, or "" if none applies. A per-registry entry
+// in credHelpers always takes precedence over the global credsStore.
+func credHelperFor(auths *dockerConfigFile, key string) string {
+	host, _ := normalizeAuthFileKey(key)
+	if helper, ok := auths.CredHelpers[host]; ok {
+		return helper
+	}
+	return auths.CredsStore
+}
+
+// helperBinaryName returns the name of the helper program that implements
+// the Docker credential-helper protocol for helper, e.g. "osxkeychain"
+// becomes "docker-credential-osxkeychain".
+func helperBinaryName(helper string) string {
+	return "docker-credential-" + helper
+}
+
+// getCredHelperCredentials retrieves the credentials for registry from the
+// external helper program implementing helper. A registry with no stored
+// credentials is not an error; an empty types.DockerAuthConfig is returned.
+func getCredHelperCredentials(helper, registry string) (types.DockerAuthConfig, error) {
+	name := helperBinaryName(helper)
+	creds, err := helperclient.Get(helperclient.NewShellProgramFunc(name), registry)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFound(err) {
+			return types.DockerAuthConfig{}, nil
+		}
+		return types.DockerAuthConfig{}, errors.Wrapf(err, "error getting credentials for %q from credential helper %q", registry, name)
+	}
+
+	return types.DockerAuthConfig{
+		Username: creds.Username,
+		Password: creds.Secret,
+	}, nil
+}
+
+// setCredHelperCredentials stores username/password for registry using the
+// external helper program implementing helper.
+func setCredHelperCredentials(helper, registry, username, password string) error {
+	name := helperBinaryName(helper)
+	creds := &credentials.Credentials{
+		ServerURL: registry,
+		Username:  username,
+		Secret:    password,
+	}
+	if err := helperclient.Store(helperclient.NewShellProgramFunc(name), creds); err != nil {
+		return errors.Wrapf(err, "error storing credentials for %q in credential helper %q", registry, name)
+	}
+	return nil
+}
+
+// eraseCredHelperCredentials removes any credentials stored for registry
+// using the external helper program implementing helper.
+func eraseCredHelperCredentials(helper, registry string) error {
+	name := helperBinaryName(helper)
+	if err := helperclient.Erase(helperclient.NewShellProgramFunc(name), registry); err != nil {
+		return errors.Wrapf(err, "error erasing credentials for %q from credential helper %q", registry, name)
+	}
+	return nil
+}
+
+// listCredHelperRegistries returns the registries with credentials stored
+// in the external helper program implementing helper.
+func listCredHelperRegistries(helper string) ([]string, error) {
+	name := helperBinaryName(helper)
+	registries, err := helperclient.List(helperclient.NewShellProgramFunc(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing credentials from credential helper %q", name)
+	}
+
+	result := make([]string, 0, len(registries))
+	for registry := range registries {
+		result = append(result, registry)
+	}
+	return result, nil
+}
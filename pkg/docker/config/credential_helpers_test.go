@@ -0,0 +1,249 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHelperDBEnvVar names the environment variable that points the fake
+// credential helper (below) at its on-disk, JSON-encoded credential store.
+const fakeHelperDBEnvVar = "CONTAINERS_FAKE_CRED_HELPER_DB"
+
+// TestMain re-executes this test binary as a fake docker-credential-helper
+// when invoked under that name, so the suite can exercise the real
+// exec/stdin/stdout protocol without requiring an external helper binary
+// to be installed.
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "docker-credential-fake" {
+		os.Exit(runFakeCredentialHelper())
+	}
+	os.Exit(m.Run())
+}
+
+func loadFakeHelperDB(path string) map[string]credentials.Credentials {
+	db := map[string]credentials.Credentials{}
+	raw, err := ioutil.ReadFile(path)
+	if err == nil {
+		_ = json.Unmarshal(raw, &db)
+	}
+	return db
+}
+
+func saveFakeHelperDB(path string, db map[string]credentials.Credentials) {
+	raw, err := json.Marshal(db)
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		panic(err)
+	}
+}
+
+// runFakeCredentialHelper implements enough of the documented
+// get/store/erase/list protocol to exercise pkg/docker/config's credential
+// helper support, persisting state to the file named by fakeHelperDBEnvVar
+// across the process' short lifetime.
+func runFakeCredentialHelper() int {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stdout, "usage: docker-credential-fake <get|store|erase|list>")
+		return 1
+	}
+
+	dbPath := os.Getenv(fakeHelperDBEnvVar)
+	db := loadFakeHelperDB(dbPath)
+
+	switch os.Args[1] {
+	case "store":
+		var creds credentials.Credentials
+		if err := json.NewDecoder(os.Stdin).Decode(&creds); err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			return 1
+		}
+		db[creds.ServerURL] = creds
+		saveFakeHelperDB(dbPath, db)
+
+	case "get":
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			return 1
+		}
+		server := strings.TrimSpace(string(raw))
+		creds, ok := db[server]
+		if !ok {
+			fmt.Fprint(os.Stdout, "credentials not found in native keychain")
+			return 1
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(creds); err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			return 1
+		}
+
+	case "erase":
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			return 1
+		}
+		delete(db, strings.TrimSpace(string(raw)))
+		saveFakeHelperDB(dbPath, db)
+
+	case "list":
+		listing := map[string]string{}
+		for server, creds := range db {
+			listing[server] = creds.Username
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(listing); err != nil {
+			fmt.Fprintln(os.Stdout, err)
+			return 1
+		}
+
+	default:
+		fmt.Fprintf(os.Stdout, "unknown verb %q\n", os.Args[1])
+		return 1
+	}
+
+	return 0
+}
+
+// installFakeCredHelper puts a "docker-credential-fake" symlink to this
+// test binary on PATH, and points its backing store at a fresh, empty
+// database file.
+func installFakeCredHelper(t *testing.T) {
+	self, err := os.Executable()
+	require.NoError(t, err)
+
+	binDir := t.TempDir()
+	require.NoError(t, os.Symlink(self, filepath.Join(binDir, "docker-credential-fake")))
+
+	dbFile, err := ioutil.TempFile(t.TempDir(), "fake-helper-db")
+	require.NoError(t, err)
+	require.NoError(t, dbFile.Close())
+
+	t.Setenv(fakeHelperDBEnvVar, dbFile.Name())
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCredHelperGetSetErase(t *testing.T) {
+	installFakeCredHelper(t)
+
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	defer os.RemoveAll(tmpFile.Name())
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(`{"credsStore":"fake"}`), 0600))
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	// Nothing stored yet.
+	auth, err := GetCredentials(sys, "quay.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+
+	require.NoError(t, SetAuthentication(sys, "quay.io", "fake-user", "fake-password"))
+
+	auth, err = GetCredentials(sys, "quay.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, auth)
+
+	// SetAuthentication must not have written the plaintext secret into
+	// the JSON file.
+	raw, err := ioutil.ReadFile(tmpFile.Name())
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "fake-password")
+
+	all, err := GetAllCredentials(sys)
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, all["quay.io"])
+
+	require.NoError(t, RemoveAuthentication(sys, "quay.io"))
+	auth, err = GetCredentials(sys, "quay.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+}
+
+func TestCredHelperNormalizesTaggedAndNamespacedScope(t *testing.T) {
+	installFakeCredHelper(t)
+
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	defer os.RemoveAll(tmpFile.Name())
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(`{"credsStore":"fake"}`), 0600))
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	// A tagged, repository-scoped reference must be stored and retrievable
+	// under the identical key: both SetCredentials and GetCredentials
+	// reduce it to its bare host ("quay.io") before talking to the helper.
+	require.NoError(t, SetCredentials(sys, "quay.io/libpod/podman:latest", "fake-user", "fake-password"))
+
+	auth, err := GetCredentials(sys, "quay.io/libpod/podman:latest")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, auth)
+
+	require.NoError(t, RemoveCredentials(sys, "quay.io/libpod/podman:latest"))
+	auth, err = GetCredentials(sys, "quay.io/libpod/podman:latest")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+}
+
+func TestCredHelperHostLoginAppliesToRepositoryScopedPulls(t *testing.T) {
+	installFakeCredHelper(t)
+
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	defer os.RemoveAll(tmpFile.Name())
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(`{"credsStore":"fake"}`), 0600))
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	// docker login quay.io
+	require.NoError(t, SetAuthentication(sys, "quay.io", "fake-user", "fake-password"))
+
+	// A real pull/push reference always includes a repository path; it
+	// must still resolve to the host-scoped credential.
+	auth, err := GetCredentials(sys, "quay.io/libpod/podman:latest")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, auth)
+
+	all, err := GetAllCredentials(sys)
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, all["quay.io"])
+}
+
+func TestCredHelperPerRegistryOverridesCredsStore(t *testing.T) {
+	installFakeCredHelper(t)
+
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	defer os.RemoveAll(tmpFile.Name())
+	config := `{"credsStore":"missing-helper","credHelpers":{"quay.io":"fake"}}`
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(config), 0600))
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	require.NoError(t, SetAuthentication(sys, "quay.io", "fake-user", "fake-password"))
+	auth, err := GetCredentials(sys, "quay.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "fake-user", Password: "fake-password"}, auth)
+
+	// The global credsStore names a nonexistent helper; since example.org
+	// has no credHelpers override, resolving it must fail identifying
+	// that binary.
+	_, err = GetCredentials(sys, "example.org")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "docker-credential-missing-helper")
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"github.com/containers/image/v5/types"
+)
+
+// proxyConfig is a single entry of dockerConfigFile.Proxies, matching the
+// shape Docker's CLI writes for each context (keyed by context name, or
+// "default").
+type proxyConfig struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	FTPProxy   string `json:"ftpProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+}
+
+// ProxyConfig holds the proxy environment variables Docker's CLI associates
+// with a registry or context, as read from config.json's "proxies" section.
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	FTPProxy   string
+	NoProxy    string
+}
+
+// GetDefaultHTTPHeaders returns the "HttpHeaders" map from the
+// authentication file governed by sys: extra headers Docker's CLI sends
+// with every registry HTTP request (e.g. a custom User-Agent). A missing
+// section returns a nil map.
+func GetDefaultHTTPHeaders(sys *types.SystemContext) (map[string]string, error) {
+	path, legacyFormat, err := getPathToAuth(sys)
+	if err != nil {
+		return nil, err
+	}
+	if legacyFormat {
+		return nil, nil
+	}
+
+	auths, err := readJSONFile(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return auths.HTTPHeaders, nil
+}
+
+// GetProxyConfig returns the proxy settings that apply to registry, as read
+// from the "proxies" section of the authentication file governed by sys.
+// A registry-specific entry is preferred; otherwise the "default" entry is
+// used. found is false if neither exists.
+func GetProxyConfig(sys *types.SystemContext, registry string) (conf ProxyConfig, found bool, err error) {
+	path, legacyFormat, err := getPathToAuth(sys)
+	if err != nil {
+		return ProxyConfig{}, false, err
+	}
+	if legacyFormat {
+		return ProxyConfig{}, false, nil
+	}
+
+	auths, err := readJSONFile(path, false)
+	if err != nil {
+		return ProxyConfig{}, false, err
+	}
+
+	entry, ok := auths.Proxies[registry]
+	if !ok {
+		entry, ok = auths.Proxies["default"]
+	}
+	if !ok {
+		return ProxyConfig{}, false, nil
+	}
+
+	return ProxyConfig{
+		HTTPProxy:  entry.HTTPProxy,
+		HTTPSProxy: entry.HTTPSProxy,
+		FTPProxy:   entry.FTPProxy,
+		NoProxy:    entry.NoProxy,
+	}, true, nil
+}
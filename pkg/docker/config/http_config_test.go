@@ -0,0 +1,98 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const httpConfigTestdata = `{
+	"auths": {
+		"example.org": {"auth": "ZXhhbXBsZTpvcmc="}
+	},
+	"HttpHeaders": {
+		"User-Agent": "my-client/1.0"
+	},
+	"proxies": {
+		"default": {
+			"httpProxy": "http://proxy.example.com:3128",
+			"httpsProxy": "https://proxy.example.com:3130",
+			"noProxy": "localhost,127.0.0.1"
+		},
+		"registry.example.com": {
+			"httpProxy": "http://registry-proxy.example.com:3128"
+		}
+	}
+}`
+
+func TestGetDefaultHTTPHeaders(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte(httpConfigTestdata))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+	headers, err := GetDefaultHTTPHeaders(sys)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"User-Agent": "my-client/1.0"}, headers)
+}
+
+func TestGetProxyConfig(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte(httpConfigTestdata))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	conf, found, err := GetProxyConfig(sys, "registry.example.com")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ProxyConfig{HTTPProxy: "http://registry-proxy.example.com:3128"}, conf)
+
+	conf, found, err = GetProxyConfig(sys, "other.example.com")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ProxyConfig{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "https://proxy.example.com:3130",
+		NoProxy:    "localhost,127.0.0.1",
+	}, conf)
+
+	_, found, err = GetProxyConfig(&types.SystemContext{AuthFilePath: tmpFile.Name() + ".missing"}, "other.example.com")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestSetAuthenticationPreservesHTTPHeadersAndProxies(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte(httpConfigTestdata))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+	require.NoError(t, SetAuthentication(sys, "example.org", "new-user", "new-password"))
+
+	auth, err := GetCredentials(sys, "example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "new-user", Password: "new-password"}, auth)
+
+	headers, err := GetDefaultHTTPHeaders(sys)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"User-Agent": "my-client/1.0"}, headers)
+
+	conf, found, err := GetProxyConfig(sys, "registry.example.com")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, ProxyConfig{HTTPProxy: "http://registry-proxy.example.com:3128"}, conf)
+}
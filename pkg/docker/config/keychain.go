@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+
+	"github.com/containers/image/v5/types"
+)
+
+// Keychain resolves registry credentials for ref, a registry hostname or a
+// repository reference scope such as "quay.io/libpod/podman:latest". It
+// generalizes GetCredentials to let callers compose or substitute their own
+// credential-lookup order (e.g. Podman's auth.json before Docker's
+// config.json, or environment variables before either).
+type Keychain interface {
+	Resolve(ref string) (types.DockerAuthConfig, error)
+}
+
+// DockerConfigKeychain resolves credentials the same way GetCredentials
+// does: Sys, if non-nil, selects the authentication file(s) to search;
+// a nil Sys reproduces today's default lookup order.
+type DockerConfigKeychain struct {
+	Sys *types.SystemContext
+}
+
+// Resolve implements Keychain.
+func (k DockerConfigKeychain) Resolve(ref string) (types.DockerAuthConfig, error) {
+	return GetCredentials(k.Sys, ref)
+}
+
+// PodmanAuthKeychain resolves credentials from Podman's auth.json
+// (XDG_RUNTIME_DIR/containers/auth.json, or the per-OS equivalent),
+// ignoring Docker's config.json and .dockercfg.
+type PodmanAuthKeychain struct{}
+
+// Resolve implements Keychain.
+func (PodmanAuthKeychain) Resolve(ref string) (types.DockerAuthConfig, error) {
+	path, legacyFormat, err := getPathToAuth(nil)
+	if err != nil {
+		return types.DockerAuthConfig{}, err
+	}
+	host, target := normalizeAuthFileKey(ref)
+	auth, _, err := lookupCredentialsInPath(authPath{path: path, legacyFormat: legacyFormat}, host, target)
+	return auth, err
+}
+
+// EnvKeychain resolves credentials from the environment: REGISTRY_AUTH_FILE
+// names an authentication file to search (in the same format as
+// SystemContext.AuthFilePath); otherwise DOCKER_USERNAME/DOCKER_PASSWORD
+// supply a single set of credentials used for every ref. When
+// REGISTRY_AUTH_FILE is set, DOCKER_USERNAME/DOCKER_PASSWORD are not
+// consulted, even if the file has no entry for ref.
+type EnvKeychain struct{}
+
+// Resolve implements Keychain.
+func (EnvKeychain) Resolve(ref string) (types.DockerAuthConfig, error) {
+	if path := os.Getenv("REGISTRY_AUTH_FILE"); path != "" {
+		return GetCredentials(&types.SystemContext{AuthFilePath: path}, ref)
+	}
+
+	username, password := os.Getenv("DOCKER_USERNAME"), os.Getenv("DOCKER_PASSWORD")
+	if username != "" || password != "" {
+		return types.DockerAuthConfig{Username: username, Password: password}, nil
+	}
+
+	return types.DockerAuthConfig{}, nil
+}
+
+// AnonymousKeychain never finds any credentials. It is useful as the last
+// entry of a MultiKeychain to make anonymous access the explicit, final
+// fallback.
+type AnonymousKeychain struct{}
+
+// Resolve implements Keychain.
+func (AnonymousKeychain) Resolve(ref string) (types.DockerAuthConfig, error) {
+	return types.DockerAuthConfig{}, nil
+}
+
+// multiKeychain tries each of its keychains in order, returning the first
+// non-anonymous (non-empty) result.
+type multiKeychain struct {
+	keychains []Keychain
+}
+
+// MultiKeychain returns a Keychain which tries each of keychains in order
+// and returns the first non-anonymous hit.
+func MultiKeychain(keychains ...Keychain) Keychain {
+	return multiKeychain{keychains: keychains}
+}
+
+// Resolve implements Keychain.
+func (m multiKeychain) Resolve(ref string) (types.DockerAuthConfig, error) {
+	for _, kc := range m.keychains {
+		auth, err := kc.Resolve(ref)
+		if err != nil {
+			return types.DockerAuthConfig{}, err
+		}
+		if auth != (types.DockerAuthConfig{}) {
+			return auth, nil
+		}
+	}
+	return types.DockerAuthConfig{}, nil
+}
+
+// DefaultKeychain reproduces the lookup order GetCredentials has always
+// used: a nil-Sys DockerConfigKeychain.
+var DefaultKeychain Keychain = DockerConfigKeychain{}
+
+// GetCredentialsUsingKeychain resolves key via kc, falling back to
+// DefaultKeychain if kc is nil.
+//
+// NOTE: this is not wired into GetCredentials/SystemContext. Doing so needs
+// a Keychain field on types.SystemContext, which this package cannot add:
+// SystemContext is defined upstream, in github.com/containers/image/v5/types,
+// outside this repository. Until that field exists upstream (and
+// getCredentialsWithHomeDir is updated to consult it when set), callers who
+// want Keychain-based lookups (e.g. transports wanting to inject
+// MultiKeychain(EnvKeychain{}, PodmanAuthKeychain{})) must call this function
+// directly instead of going through GetCredentials. Flagging this
+// acceptance criterion for explicit descoping until the upstream field
+// lands: this needs to be tracked as a follow-up against
+// containers/image/v5/types, not treated as satisfied by this package
+// alone, since nothing here exercises Keychain through GetCredentials
+// itself.
+func GetCredentialsUsingKeychain(kc Keychain, key string) (types.DockerAuthConfig, error) {
+	if kc == nil {
+		kc = DefaultKeychain
+	}
+	return kc.Resolve(key)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousKeychain(t *testing.T) {
+	auth, err := AnonymousKeychain{}.Resolve("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+}
+
+func TestEnvKeychain(t *testing.T) {
+	t.Run("no environment set", func(t *testing.T) {
+		auth, err := EnvKeychain{}.Resolve("example.org")
+		require.NoError(t, err)
+		assert.Equal(t, types.DockerAuthConfig{}, auth)
+	})
+
+	t.Run("DOCKER_USERNAME/DOCKER_PASSWORD", func(t *testing.T) {
+		t.Setenv("DOCKER_USERNAME", "env-user")
+		t.Setenv("DOCKER_PASSWORD", "env-password")
+		auth, err := EnvKeychain{}.Resolve("example.org")
+		require.NoError(t, err)
+		assert.Equal(t, types.DockerAuthConfig{Username: "env-user", Password: "env-password"}, auth)
+	})
+
+	t.Run("REGISTRY_AUTH_FILE", func(t *testing.T) {
+		tmpFile, err := ioutil.TempFile("", "auth.json.")
+		require.NoError(t, err)
+		defer os.RemoveAll(tmpFile.Name())
+		_, err = tmpFile.Write([]byte("{}"))
+		require.NoError(t, err)
+		require.NoError(t, tmpFile.Close())
+		require.NoError(t, SetAuthentication(&types.SystemContext{AuthFilePath: tmpFile.Name()}, "example.org", "file-user", "file-password"))
+
+		t.Setenv("REGISTRY_AUTH_FILE", tmpFile.Name())
+		auth, err := EnvKeychain{}.Resolve("example.org")
+		require.NoError(t, err)
+		assert.Equal(t, types.DockerAuthConfig{Username: "file-user", Password: "file-password"}, auth)
+	})
+}
+
+func TestMultiKeychainReturnsFirstNonAnonymousHit(t *testing.T) {
+	kc := MultiKeychain(AnonymousKeychain{}, EnvKeychain{}, AnonymousKeychain{})
+
+	auth, err := kc.Resolve("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+
+	t.Setenv("DOCKER_USERNAME", "multi-user")
+	t.Setenv("DOCKER_PASSWORD", "multi-password")
+	auth, err = kc.Resolve("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "multi-user", Password: "multi-password"}, auth)
+}
+
+func TestPodmanAuthKeychain(t *testing.T) {
+	tmpXDGRuntimeDir, err := ioutil.TempDir("", "test_podman_keychain")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpXDGRuntimeDir)
+	t.Setenv("XDG_RUNTIME_DIR", tmpXDGRuntimeDir)
+
+	configDir := filepath.Join(tmpXDGRuntimeDir, "containers")
+	require.NoError(t, os.MkdirAll(configDir, 0700))
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "example.json"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "auth.json"), contents, 0640))
+
+	auth, err := PodmanAuthKeychain{}.Resolve("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "example", Password: "org"}, auth)
+}
+
+func TestDockerConfigKeychain(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+	require.NoError(t, SetAuthentication(sys, "example.org", "kc-user", "kc-password"))
+
+	auth, err := (DockerConfigKeychain{Sys: sys}).Resolve("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "kc-user", Password: "kc-password"}, auth)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+)
+
+// xRegistryAuthConfig is the shape of the single-entry document used by the
+// X-Registry-Auth header, matching Docker's AuthConfig wire format.
+type xRegistryAuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// encodeDockerAuthConfig is the inverse of decodeDockerAuth: it packs auth
+// into the "auth"+"identitytoken" fields used by dockerConfigFile entries.
+func encodeDockerAuthConfig(auth types.DockerAuthConfig) dockerAuthConfig {
+	return dockerAuthConfig{
+		Auth:          base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+		IdentityToken: auth.IdentityToken,
+	}
+}
+
+// MakeXRegistryConfigHeader returns the X-Registry-Config header used by
+// Docker's build/push API: the base64url-encoded JSON of
+// {"auths": {server: {"auth": ..., "identitytoken": ...}}}, merging the
+// credentials found by GetAllCredentials(sys) with extraCreds (which take
+// precedence on conflicts).
+func MakeXRegistryConfigHeader(sys *types.SystemContext, extraCreds map[string]types.DockerAuthConfig) (http.Header, error) {
+	merged, err := GetAllCredentials(sys)
+	if err != nil {
+		return nil, err
+	}
+	for server, auth := range extraCreds {
+		merged[server] = auth
+	}
+
+	var doc dockerConfigFile
+	if len(merged) > 0 {
+		doc.AuthConfigs = make(map[string]dockerAuthConfig, len(merged))
+		for server, auth := range merged {
+			doc.AuthConfigs[server] = encodeDockerAuthConfig(auth)
+		}
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling X-Registry-Config header")
+	}
+
+	header := http.Header{}
+	header.Set("X-Registry-Config", base64.URLEncoding.EncodeToString(raw))
+	return header, nil
+}
+
+// ParseXRegistryConfigHeader parses a X-Registry-Config header as produced
+// by MakeXRegistryConfigHeader (or by Docker's own clients), returning the
+// credentials keyed by server. A missing header returns an empty map.
+func ParseXRegistryConfigHeader(h http.Header) (map[string]types.DockerAuthConfig, error) {
+	encoded := h.Get("X-Registry-Config")
+	if encoded == "" {
+		return map[string]types.DockerAuthConfig{}, nil
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding X-Registry-Config header")
+	}
+
+	var doc dockerConfigFile
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling X-Registry-Config header")
+	}
+
+	result := make(map[string]types.DockerAuthConfig, len(doc.AuthConfigs))
+	for server, entry := range doc.AuthConfigs {
+		auth, err := decodeDockerAuth(entry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding credentials for %q", server)
+		}
+		result[server] = auth
+	}
+	return result, nil
+}
+
+// MakeXRegistryAuthHeader returns the single-registry X-Registry-Auth
+// header used by Docker's pull/push API: the base64url-encoded JSON of a
+// single AuthConfig document for registry. If auth is non-nil, it is used
+// directly; otherwise the credentials are looked up via GetCredentials(sys,
+// registry).
+func MakeXRegistryAuthHeader(sys *types.SystemContext, registry string, auth *types.DockerAuthConfig) (http.Header, error) {
+	var resolved types.DockerAuthConfig
+	if auth != nil {
+		resolved = *auth
+	} else {
+		var err error
+		resolved, err = GetCredentials(sys, registry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := xRegistryAuthConfig{
+		Username:      resolved.Username,
+		Password:      resolved.Password,
+		ServerAddress: registry,
+		IdentityToken: resolved.IdentityToken,
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling X-Registry-Auth header")
+	}
+
+	header := http.Header{}
+	header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(raw))
+	return header, nil
+}
+
+// ParseXRegistryAuthHeader parses a X-Registry-Auth header as produced by
+// MakeXRegistryAuthHeader (or by Docker's own clients). A missing header
+// returns an empty types.DockerAuthConfig.
+func ParseXRegistryAuthHeader(h http.Header) (types.DockerAuthConfig, error) {
+	encoded := h.Get("X-Registry-Auth")
+	if encoded == "" {
+		return types.DockerAuthConfig{}, nil
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return types.DockerAuthConfig{}, errors.Wrap(err, "error decoding X-Registry-Auth header")
+	}
+
+	var doc xRegistryAuthConfig
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return types.DockerAuthConfig{}, errors.Wrap(err, "error unmarshaling X-Registry-Auth header")
+	}
+
+	return types.DockerAuthConfig{
+		Username:      doc.Username,
+		Password:      doc.Password,
+		IdentityToken: doc.IdentityToken,
+	}, nil
+}
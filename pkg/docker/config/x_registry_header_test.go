@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeAndParseXRegistryConfigHeaderRoundTrip(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+	require.NoError(t, SetAuthentication(sys, "example.org", "example-user", "example-password"))
+
+	extra := map[string]types.DockerAuthConfig{
+		"quay.io":     {IdentityToken: "extra-identity-token"},
+		"example.org": {Username: "override-user", Password: "override-password"},
+	}
+
+	header, err := MakeXRegistryConfigHeader(sys, extra)
+	require.NoError(t, err)
+
+	parsed, err := ParseXRegistryConfigHeader(header)
+	require.NoError(t, err)
+
+	all, err := GetAllCredentials(sys)
+	require.NoError(t, err)
+	expected := map[string]types.DockerAuthConfig{}
+	for k, v := range all {
+		expected[k] = v
+	}
+	for k, v := range extra {
+		expected[k] = v
+	}
+	assert.Equal(t, expected, parsed)
+
+	// The override took effect and the identity token survived the round trip.
+	assert.Equal(t, types.DockerAuthConfig{Username: "override-user", Password: "override-password"}, parsed["example.org"])
+	assert.Equal(t, types.DockerAuthConfig{IdentityToken: "extra-identity-token"}, parsed["quay.io"])
+}
+
+func TestMakeXRegistryConfigHeaderEmptyIsNull(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "auth.json.")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpFile.Name())
+	_, err = tmpFile.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+
+	sys := &types.SystemContext{AuthFilePath: tmpFile.Name()}
+
+	header, err := MakeXRegistryConfigHeader(sys, nil)
+	require.NoError(t, err)
+
+	raw, err := base64.URLEncoding.DecodeString(header.Get("X-Registry-Config"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"auths":null}`, string(raw))
+
+	parsed, err := ParseXRegistryConfigHeader(header)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.DockerAuthConfig{}, parsed)
+}
+
+func TestParseXRegistryConfigHeaderMissing(t *testing.T) {
+	parsed, err := ParseXRegistryConfigHeader(http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.DockerAuthConfig{}, parsed)
+}
+
+func TestParseXRegistryConfigHeaderMalformedBase64(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Registry-Config", "not-valid-base64!!!")
+
+	_, err := ParseXRegistryConfigHeader(h)
+	require.Error(t, err)
+	_, ok := errors.Cause(err).(base64.CorruptInputError)
+	assert.True(t, ok, "expected a base64.CorruptInputError, got %#v", errors.Cause(err))
+}
+
+func TestMakeAndParseXRegistryAuthHeaderRoundTrip(t *testing.T) {
+	auth := types.DockerAuthConfig{Username: "solo-user", Password: "solo-password", IdentityToken: "solo-token"}
+
+	header, err := MakeXRegistryAuthHeader(nil, "example.org", &auth)
+	require.NoError(t, err)
+
+	parsed, err := ParseXRegistryAuthHeader(header)
+	require.NoError(t, err)
+	assert.Equal(t, auth, parsed)
+}
+
+func TestParseXRegistryAuthHeaderMissing(t *testing.T) {
+	parsed, err := ParseXRegistryAuthHeader(http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, parsed)
+}
+
+func TestParseXRegistryAuthHeaderMalformedBase64(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Registry-Auth", "not-valid-base64!!!")
+
+	_, err := ParseXRegistryAuthHeader(h)
+	require.Error(t, err)
+	_, ok := errors.Cause(err).(base64.CorruptInputError)
+	assert.True(t, ok, "expected a base64.CorruptInputError, got %#v", errors.Cause(err))
+}